@@ -0,0 +1,41 @@
+// Package config defines the on-disk (TOML) configuration shape for
+// carbon-clickhouse.
+package config
+
+import (
+	"github.com/lomik/carbon-clickhouse/relabel"
+)
+
+// DedicatedTag routes a single tag into its own ClickHouse column instead of
+// letting it fall into the generic Tags column.
+type DedicatedTag struct {
+	Name   string `toml:"name"`
+	Column string `toml:"column"`
+}
+
+// TagCardinalityLimit bounds how many distinct values a single tag may take
+// before its overflow Policy kicks in.
+type TagCardinalityLimit struct {
+	Tag    string `toml:"tag"`
+	Limit  int    `toml:"limit"`
+	Policy string `toml:"policy"` // "drop_record", "drop_tag" or "overflow_value"
+}
+
+// Uploader is the configuration section for a single upload target (one
+// per [upload.*] table in the config file).
+type Uploader struct {
+	Type      string `toml:"type"`
+	TableName string `toml:"table"`
+	URL       string `toml:"url"`
+
+	// Compression is one of "none" (default), "gzip" or "zstd"; CompressionLevel
+	// is passed to the chosen encoder and ignored for "none".
+	Compression      string `toml:"compression"`
+	CompressionLevel int    `toml:"compression_level"`
+
+	// Tagged-specific knobs.
+	DedicatedTags        []DedicatedTag        `toml:"dedicated_tags"`
+	IgnoredTaggedMetrics []string              `toml:"ignored_tagged_metrics"`
+	RelabelConfigs       []relabel.Config      `toml:"relabel_configs"`
+	TagCardinalityLimits []TagCardinalityLimit `toml:"tag_cardinality_limits"`
+}
@@ -0,0 +1,159 @@
+// Package relabel implements a small subset of Prometheus-style
+// relabel_configs, applied to the tag set of a single tagged metric
+// before it is written out.
+package relabel
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Action selects what a Rule does with the tags it matches.
+type Action string
+
+const (
+	Replace   Action = "replace"
+	Drop      Action = "drop"
+	Keep      Action = "keep"
+	LabelDrop Action = "labeldrop"
+	LabelKeep Action = "labelkeep"
+	HashMod   Action = "hashmod"
+)
+
+const defaultSeparator = ";"
+
+// Config is the user-facing, uncompiled description of a single rule.
+type Config struct {
+	SourceLabels []string `toml:"source_labels"`
+	Separator    string   `toml:"separator"`
+	Regex        string   `toml:"regex"`
+	TargetLabel  string   `toml:"target_label"`
+	Replacement  string   `toml:"replacement"`
+	Action       Action   `toml:"action"`
+	Modulus      uint64   `toml:"modulus"`
+}
+
+// Rule is a compiled Config, ready to be applied to a tag set.
+type Rule struct {
+	sourceLabels []string
+	separator    string
+	regex        *regexp.Regexp
+	targetLabel  string
+	replacement  string
+	action       Action
+	modulus      uint64
+}
+
+// NewRule compiles a Config into a Rule, validating the regex and action.
+func NewRule(cfg Config) (*Rule, error) {
+	action := cfg.Action
+	if action == "" {
+		action = Replace
+	}
+
+	switch action {
+	case Replace, Drop, Keep, LabelDrop, LabelKeep, HashMod:
+		// ok
+	default:
+		return nil, fmt.Errorf("relabel: unknown action %q", cfg.Action)
+	}
+
+	expr := cfg.Regex
+	if expr == "" {
+		expr = "(.*)"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("relabel: invalid regex %q: %w", cfg.Regex, err)
+	}
+
+	separator := cfg.Separator
+	if separator == "" {
+		separator = defaultSeparator
+	}
+
+	if action == HashMod && cfg.Modulus == 0 {
+		return nil, fmt.Errorf("relabel: hashmod action requires modulus > 0")
+	}
+
+	return &Rule{
+		sourceLabels: cfg.SourceLabels,
+		separator:    separator,
+		regex:        re,
+		targetLabel:  cfg.TargetLabel,
+		replacement:  cfg.Replacement,
+		action:       action,
+		modulus:      cfg.Modulus,
+	}, nil
+}
+
+// sourceValue joins the values of the configured source labels with the
+// rule's separator, mirroring Prometheus relabeling semantics.
+func (r *Rule) sourceValue(tags map[string]string) string {
+	if len(r.sourceLabels) == 0 {
+		return ""
+	}
+	values := make([]string, len(r.sourceLabels))
+	for i, label := range r.sourceLabels {
+		values[i] = tags[label]
+	}
+	return strings.Join(values, r.separator)
+}
+
+// Apply runs a single rule against tags, returning the (possibly mutated)
+// tag set and whether the record should still be kept.
+func (r *Rule) Apply(tags map[string]string) (map[string]string, bool) {
+	switch r.action {
+	case Drop:
+		if r.regex.MatchString(r.sourceValue(tags)) {
+			return tags, false
+		}
+	case Keep:
+		if !r.regex.MatchString(r.sourceValue(tags)) {
+			return tags, false
+		}
+	case LabelDrop:
+		// __name__ is never subject to labeldrop/labelkeep: dropping it would
+		// leave the record with no metric name at all.
+		for name := range tags {
+			if name != "__name__" && r.regex.MatchString(name) {
+				delete(tags, name)
+			}
+		}
+	case LabelKeep:
+		for name := range tags {
+			if name != "__name__" && !r.regex.MatchString(name) {
+				delete(tags, name)
+			}
+		}
+	case HashMod:
+		sum := fnv.New64a()
+		_, _ = sum.Write([]byte(r.sourceValue(tags)))
+		tags[r.targetLabel] = strconv.FormatUint(sum.Sum64()%r.modulus, 10)
+	case Replace:
+		value := r.sourceValue(tags)
+		match := r.regex.FindStringSubmatchIndex(value)
+		if match == nil {
+			break
+		}
+		tags[r.targetLabel] = string(r.regex.ExpandString(nil, r.replacement, value, match))
+	}
+
+	return tags, true
+}
+
+// Apply runs the full rule chain against tags in order, stopping early if
+// a drop/keep rule rejects the record.
+func Apply(rules []*Rule, tags map[string]string) (map[string]string, bool) {
+	keep := true
+	for _, r := range rules {
+		tags, keep = r.Apply(tags)
+		if !keep {
+			return tags, false
+		}
+	}
+	return tags, true
+}
@@ -0,0 +1,107 @@
+package relabel
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyChain(t *testing.T) {
+	rules := make([]*Rule, 0, 3)
+	for _, cfg := range []Config{
+		{
+			SourceLabels: []string{"__name__"},
+			Regex:        `^internal\..*$`,
+			Action:       Drop,
+		},
+		{
+			SourceLabels: []string{"env"},
+			Regex:        `^(prod|staging)$`,
+			TargetLabel:  "env_group",
+			Replacement:  "$1",
+			Action:       Replace,
+		},
+		{
+			Regex:  `^request_id$`,
+			Action: LabelDrop,
+		},
+	} {
+		rule, err := NewRule(cfg)
+		if err != nil {
+			t.Fatalf("NewRule(%+v): %v", cfg, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	tags, keep := Apply(rules, map[string]string{
+		"__name__":   "app.requests",
+		"env":        "prod",
+		"request_id": "abc-123",
+	})
+	if !keep {
+		t.Fatalf("expected record to be kept")
+	}
+
+	want := map[string]string{
+		"__name__":  "app.requests",
+		"env":       "prod",
+		"env_group": "prod",
+	}
+	if !reflect.DeepEqual(tags, want) {
+		t.Fatalf("tags = %#v, want %#v", tags, want)
+	}
+}
+
+func TestApplyLabelDropKeepsMetricName(t *testing.T) {
+	rule, err := NewRule(Config{
+		Regex:  `^__name__$`,
+		Action: LabelDrop,
+	})
+	if err != nil {
+		t.Fatalf("NewRule: %v", err)
+	}
+
+	tags, keep := Apply([]*Rule{rule}, map[string]string{"__name__": "app.requests", "env": "prod"})
+	if !keep {
+		t.Fatalf("expected record to be kept")
+	}
+	if tags["__name__"] != "app.requests" {
+		t.Fatalf("__name__ = %q, want it preserved by labeldrop", tags["__name__"])
+	}
+}
+
+func TestApplyLabelKeepKeepsMetricName(t *testing.T) {
+	rule, err := NewRule(Config{
+		Regex:  `^env$`,
+		Action: LabelKeep,
+	})
+	if err != nil {
+		t.Fatalf("NewRule: %v", err)
+	}
+
+	tags, keep := Apply([]*Rule{rule}, map[string]string{"__name__": "app.requests", "env": "prod", "region": "us"})
+	if !keep {
+		t.Fatalf("expected record to be kept")
+	}
+	if tags["__name__"] != "app.requests" {
+		t.Fatalf("__name__ = %q, want it preserved by labelkeep", tags["__name__"])
+	}
+	if _, ok := tags["region"]; ok {
+		t.Fatalf("region should have been dropped by labelkeep")
+	}
+}
+
+func TestApplyDrop(t *testing.T) {
+	rule, err := NewRule(Config{
+		SourceLabels: []string{"__name__"},
+		Regex:        `^internal\..*$`,
+		Action:       Drop,
+	})
+	if err != nil {
+		t.Fatalf("NewRule: %v", err)
+	}
+
+	_, keep := Apply([]*Rule{rule}, map[string]string{"__name__": "internal.debug"})
+	if keep {
+		t.Fatalf("expected record to be dropped")
+	}
+}
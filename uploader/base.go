@@ -0,0 +1,90 @@
+package uploader
+
+import (
+	"expvar"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/lomik/carbon-clickhouse/config"
+)
+
+// statRegistry is the process-wide expvar surface every Stat publishes its
+// counters through (visible at /debug/vars), keyed as "<uploader name>.<counter>".
+var statRegistry = expvar.NewMap("carbon_clickhouse_uploader_stat")
+
+// Uploader is implemented by every upload strategy (points, tagged, ...).
+type Uploader interface {
+	Start() error
+	Stop()
+}
+
+// UploaderWithReset is implemented by uploaders that keep per-cycle dedup
+// or limiter state that must be cleared between upload cycles.
+type UploaderWithReset interface {
+	Uploader
+	Reset()
+}
+
+// Stat is the counter surface every uploader publishes through, grouped by
+// uploader name (the config table name) and counter name. Every counter is
+// also published to statRegistry, so operators can read it from /debug/vars
+// without going through this type.
+type Stat struct {
+	name string
+
+	mu       sync.Mutex
+	counters map[string]*expvar.Int
+}
+
+func newStat(name string) *Stat {
+	return &Stat{
+		name:     name,
+		counters: make(map[string]*expvar.Int),
+	}
+}
+
+// Add increments the named counter by delta.
+func (s *Stat) Add(counter string, delta uint64) {
+	s.mu.Lock()
+	c, ok := s.counters[counter]
+	if !ok {
+		c = new(expvar.Int)
+		s.counters[counter] = c
+		statRegistry.Set(s.name+"."+counter, c)
+	}
+	s.mu.Unlock()
+
+	c.Add(int64(delta))
+}
+
+// Get returns the current value of the named counter.
+func (s *Stat) Get(counter string) uint64 {
+	s.mu.Lock()
+	c, ok := s.counters[counter]
+	s.mu.Unlock()
+	if !ok {
+		return 0
+	}
+	return uint64(c.Value())
+}
+
+// Base holds the state shared by every uploader implementation: its
+// resolved config section, logger, stats and the INSERT query prefix built
+// by the concrete uploader's constructor.
+type Base struct {
+	config *config.Uploader
+	logger *zap.Logger
+	stat   *Stat
+	query  string
+}
+
+// NewBase builds the shared state a concrete uploader (Tagged, Points, ...)
+// embeds via *cached.
+func NewBase(cfg *config.Uploader, logger *zap.Logger) *Base {
+	return &Base{
+		config: cfg,
+		logger: logger,
+		stat:   newStat(cfg.TableName),
+	}
+}
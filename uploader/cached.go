@@ -0,0 +1,127 @@
+package uploader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"go.uber.org/zap"
+)
+
+// parserFunc turns one queued RowBinary file into the uploader's own
+// RowBinary encoding, written to out. It returns the set of dedup keys
+// (e.g. "day:metric") it produced this cycle.
+type parserFunc func(filename string, out io.Writer) (map[string]bool, error)
+
+// existsCache lets a parser skip re-emitting a record ClickHouse is already
+// known to hold.
+type existsCache interface {
+	Exists(key string) bool
+}
+
+// nopExistsCache never reports a hit; it is the default until a real
+// "known paths" cache is configured.
+type nopExistsCache struct{}
+
+func (nopExistsCache) Exists(string) bool { return false }
+
+// cached wraps Base with the upload-cycle machinery common to every
+// uploader: run the parser, compress its output per
+// Base.config.Compression, and POST the result to ClickHouse.
+type cached struct {
+	*Base
+
+	parser      parserFunc
+	existsCache existsCache
+
+	httpClient *http.Client
+}
+
+// newCached validates base.config.Compression/CompressionLevel eagerly, so a
+// bad value fails construction instead of wedging every future uploadFile.
+func newCached(base *Base) (*cached, error) {
+	compression := Compression(base.config.Compression)
+	cw, err := wrapCompressWriter(io.Discard, compression, base.config.CompressionLevel)
+	if err != nil {
+		return nil, fmt.Errorf("compression: %w", err)
+	}
+	_ = cw.Close()
+
+	return &cached{
+		Base:        base,
+		existsCache: nopExistsCache{},
+		httpClient:  &http.Client{},
+	}, nil
+}
+
+// Start is a no-op placeholder; concrete uploaders are driven externally by
+// the file-queue watcher that calls uploadFile per queued file.
+func (c *cached) Start() error { return nil }
+
+// Stop is a no-op placeholder; see Start.
+func (c *cached) Stop() {}
+
+// Reset is a no-op: cached keeps no per-cycle state of its own. Uploaders
+// with per-cycle state (e.g. Tagged's cardinality limiters) override this
+// and call through to it.
+func (c *cached) Reset() {}
+
+// uploadFile runs filename through the parser, compresses the result per
+// Base.config.Compression/CompressionLevel, and POSTs it to ClickHouse.
+func (c *cached) uploadFile(filename string) error {
+	var buf bytes.Buffer
+
+	compression := Compression(c.config.Compression)
+
+	cw, err := wrapCompressWriter(&buf, compression, c.config.CompressionLevel)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.parser(filename, cw); err != nil {
+		_ = cw.Close()
+		return err
+	}
+	if err := cw.Close(); err != nil {
+		return err
+	}
+
+	return c.send(&buf, compression)
+}
+
+// send POSTs body to ClickHouse, setting Content-Encoding and the
+// enable_http_compression query arg when compression is enabled.
+func (c *cached) send(body *bytes.Buffer, compression Compression) error {
+	insertURL := c.config.URL + "?query=" + urlQueryEscapeInsert(c.query)
+	if param := compression.QueryParam(); param != "" {
+		insertURL += "&" + param
+	}
+
+	req, err := http.NewRequest(http.MethodPost, insertURL, body)
+	if err != nil {
+		return err
+	}
+	if enc := compression.ContentEncoding(); enc != "" {
+		req.Header.Set("Content-Encoding", enc)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		c.logger.Error("clickhouse insert failed",
+			zap.Int("status", resp.StatusCode), zap.ByteString("body", respBody))
+		return fmt.Errorf("clickhouse insert failed: %s", resp.Status)
+	}
+	return nil
+}
+
+func urlQueryEscapeInsert(query string) string {
+	return url.QueryEscape("INSERT INTO " + query + " FORMAT RowBinary")
+}
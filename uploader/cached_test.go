@@ -0,0 +1,128 @@
+package uploader
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/lomik/carbon-clickhouse/config"
+)
+
+func newTestCached(t *testing.T, serverURL, compression string) *cached {
+	t.Helper()
+	base := NewBase(&config.Uploader{
+		TableName:   "graphite_tagged",
+		URL:         serverURL,
+		Compression: compression,
+	}, zap.NewNop())
+	c, err := newCached(base)
+	if err != nil {
+		t.Fatalf("newCached: %v", err)
+	}
+	c.query = base.config.TableName
+	return c
+}
+
+func TestNewCachedRejectsUnknownCompression(t *testing.T) {
+	base := NewBase(&config.Uploader{
+		TableName:   "graphite_tagged",
+		URL:         "http://example.invalid",
+		Compression: "gzipp",
+	}, zap.NewNop())
+
+	if _, err := newCached(base); err == nil {
+		t.Fatalf("expected an error for an unknown compression")
+	}
+}
+
+func TestCachedSendSetsCompressionHeaderAndQueryArg(t *testing.T) {
+	var gotEncoding, gotQuery string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEncoding = r.Header.Get("Content-Encoding")
+		gotQuery = r.URL.RawQuery
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := newTestCached(t, srv.URL, "gzip")
+	if err := c.send(bytes.NewBufferString("payload"), CompressionGzip); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	if gotEncoding != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", gotEncoding)
+	}
+
+	q, err := url.ParseQuery(gotQuery)
+	if err != nil {
+		t.Fatalf("ParseQuery: %v", err)
+	}
+	if q.Get("enable_http_compression") != "1" {
+		t.Fatalf("query = %q, missing enable_http_compression=1", gotQuery)
+	}
+}
+
+func TestCachedSendNoCompressionOmitsHeaderAndQueryArg(t *testing.T) {
+	var gotQuery string
+	sawHeader := false
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sawHeader = len(r.Header["Content-Encoding"]) > 0
+		gotQuery = r.URL.RawQuery
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := newTestCached(t, srv.URL, "none")
+	if err := c.send(bytes.NewBufferString("payload"), CompressionNone); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	if sawHeader {
+		t.Fatalf("Content-Encoding header should be absent")
+	}
+	if strings.Contains(gotQuery, "enable_http_compression") {
+		t.Fatalf("query should not contain enable_http_compression: %q", gotQuery)
+	}
+}
+
+func TestCachedUploadFileCompressesAndSends(t *testing.T) {
+	var gotEncoding string
+	var gotBody []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEncoding = r.Header.Get("Content-Encoding")
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := newTestCached(t, srv.URL, "gzip")
+	c.parser = func(filename string, out io.Writer) (map[string]bool, error) {
+		_, err := out.Write([]byte("row-binary-payload"))
+		return map[string]bool{"k": true}, err
+	}
+
+	if err := c.uploadFile("irrelevant"); err != nil {
+		t.Fatalf("uploadFile: %v", err)
+	}
+
+	if gotEncoding != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", gotEncoding)
+	}
+	if len(gotBody) == 0 {
+		t.Fatalf("expected a non-empty compressed body")
+	}
+	if bytes.Equal(gotBody, []byte("row-binary-payload")) {
+		t.Fatalf("body was not compressed")
+	}
+}
@@ -0,0 +1,108 @@
+package uploader
+
+import (
+	"fmt"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// CardinalityPolicy controls what happens to a record whose tag value would
+// push a tracked tag past its configured cardinality limit.
+type CardinalityPolicy string
+
+const (
+	// CardinalityDropRecord discards the whole record.
+	CardinalityDropRecord CardinalityPolicy = "drop_record"
+	// CardinalityDropTag removes just the offending tag, keeping the rest of the record.
+	CardinalityDropTag CardinalityPolicy = "drop_tag"
+	// CardinalityOverflowValue substitutes the tag value with cardinalityOverflowValue.
+	CardinalityOverflowValue CardinalityPolicy = "overflow_value"
+)
+
+const cardinalityOverflowValue = "__overflow__"
+
+const cardinalityOverflowCounterPrefix = "tag_cardinality_overflow."
+
+// cardinalityLimit is a single tag's bounded distinct-value tracker: it
+// admits up to `limit` distinct values for the tag, backed by an LRU so the
+// tracked set stays bounded in memory, but it does not evict to make room
+// for new values — once `limit` distinct values have been seen, further
+// unseen values are rejected and the caller applies its configured policy
+// (drop the record, drop the tag, or substitute a sentinel).
+type cardinalityLimit struct {
+	tag    string
+	limit  int
+	policy CardinalityPolicy
+	stat   *Stat
+
+	mu     sync.Mutex
+	values *lru.Cache[string, struct{}]
+}
+
+func newCardinalityLimit(tag string, limit int, policy CardinalityPolicy, stat *Stat) (*cardinalityLimit, error) {
+	switch policy {
+	case CardinalityDropRecord, CardinalityDropTag, CardinalityOverflowValue:
+		// ok
+	default:
+		return nil, fmt.Errorf("tag_cardinality_limits: unknown policy %q", policy)
+	}
+
+	values, err := lru.New[string, struct{}](limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cardinalityLimit{
+		tag:    tag,
+		limit:  limit,
+		policy: policy,
+		stat:   stat,
+		values: values,
+	}, nil
+}
+
+// allow reports whether value is within the cardinality limit for this tag,
+// without admitting it: the caller decides the whole record's fate first
+// (a sibling tag's limit, or another check, may still drop it) and only
+// calls commit for values that end up in a record that is actually kept.
+// needsCommit is true when value is not yet tracked, so the caller must
+// call commit to reserve its place in the budget.
+func (c *cardinalityLimit) allow(value string) (allowed, needsCommit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.values.Contains(value) {
+		return true, false
+	}
+
+	if c.values.Len() >= c.limit {
+		c.stat.Add(cardinalityOverflowCounterPrefix+c.tag, 1)
+		return false, false
+	}
+
+	return true, true
+}
+
+// commit admits value into the tracked set. It must only be called for a
+// value that allow most recently reported as needing one, and only once the
+// record it belongs to is known to be kept.
+func (c *cardinalityLimit) commit(value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values.Add(value, struct{}{})
+}
+
+// cardinalityCommit is a deferred admission: a value that allow cleared for
+// a record whose fate wasn't known yet, to be committed once the record is
+// confirmed kept.
+type cardinalityCommit struct {
+	limit *cardinalityLimit
+	value string
+}
+
+func (c *cardinalityLimit) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values.Purge()
+}
@@ -0,0 +1,56 @@
+package uploader
+
+import "testing"
+
+func TestCardinalityLimitDropsUnseenValuesOnceFull(t *testing.T) {
+	stat := newStat("test")
+	limit, err := newCardinalityLimit("request_id", 2, CardinalityDropRecord, stat)
+	if err != nil {
+		t.Fatalf("newCardinalityLimit: %v", err)
+	}
+
+	for _, v := range []string{"a", "b"} {
+		allowed, needsCommit := limit.allow(v)
+		if !allowed || !needsCommit {
+			t.Fatalf("value %q should be admitted and need a commit", v)
+		}
+		limit.commit(v)
+	}
+
+	// a repeat of an already-tracked value is always allowed and needs no commit.
+	if allowed, needsCommit := limit.allow("a"); !allowed || needsCommit {
+		t.Fatalf("repeat of a tracked value should be admitted without a new commit")
+	}
+
+	if allowed, _ := limit.allow("c"); allowed {
+		t.Fatalf("a third distinct value should be rejected once the limit is reached")
+	}
+
+	if got := stat.Get(cardinalityOverflowCounterPrefix + "request_id"); got != 1 {
+		t.Fatalf("overflow counter = %d, want 1", got)
+	}
+}
+
+func TestCardinalityLimitUncommittedValueDoesNotConsumeBudget(t *testing.T) {
+	stat := newStat("test")
+	limit, err := newCardinalityLimit("request_id", 1, CardinalityDropRecord, stat)
+	if err != nil {
+		t.Fatalf("newCardinalityLimit: %v", err)
+	}
+
+	allowed, needsCommit := limit.allow("a")
+	if !allowed || !needsCommit {
+		t.Fatalf("first distinct value should be admitted and need a commit")
+	}
+	// Deliberately not committed, as if the record were dropped for an
+	// unrelated reason: the tag's budget must not be spent on it.
+	if allowed, _ := limit.allow("b"); !allowed {
+		t.Fatalf("budget should still have room since \"a\" was never committed")
+	}
+}
+
+func TestNewCardinalityLimitRejectsUnknownPolicy(t *testing.T) {
+	if _, err := newCardinalityLimit("request_id", 2, CardinalityPolicy("drop_tg"), newStat("test")); err == nil {
+		t.Fatalf("expected an error for an unknown policy")
+	}
+}
@@ -0,0 +1,72 @@
+package uploader
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression selects how RowBinary output is encoded before it is sent to
+// ClickHouse over HTTP.
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// ContentEncoding returns the HTTP Content-Encoding header value for c, or
+// "" for CompressionNone (no header should be sent).
+func (c Compression) ContentEncoding() string {
+	switch c {
+	case CompressionGzip:
+		return "gzip"
+	case CompressionZstd:
+		return "zstd"
+	default:
+		return ""
+	}
+}
+
+// QueryParam returns the extra ClickHouse HTTP query-string fragment
+// (without a leading "&") required for it to decompress the request body,
+// or "" for CompressionNone.
+func (c Compression) QueryParam() string {
+	if c == CompressionNone || c == "" {
+		return ""
+	}
+	return "enable_http_compression=1"
+}
+
+// wrapCompressWriter wraps out in a compressing io.WriteCloser according to
+// c and level. The returned writer must always be Closed by the caller to
+// flush any trailing frame/footer, including for CompressionNone where
+// Close is a no-op.
+func wrapCompressWriter(out io.Writer, c Compression, level int) (io.WriteCloser, error) {
+	switch c {
+	case "", CompressionNone:
+		return nopWriteCloser{out}, nil
+	case CompressionGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(out, level)
+	case CompressionZstd:
+		opts := make([]zstd.EOption, 0, 1)
+		if level != 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		return zstd.NewWriter(out, opts...)
+	default:
+		return nil, fmt.Errorf("uploader: unknown compression %q", c)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,32 @@
+package uploader
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func benchmarkCompressionWrite(b *testing.B, c Compression) {
+	payload := bytes.Repeat([]byte("1600000000\tenvironment.role=carbon-clickhouse.dc=iva\t42\n"), 256)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(payload)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		w, err := wrapCompressWriter(io.Discard, c, 0)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := w.Write(payload); err != nil {
+			b.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompressionNone(b *testing.B) { benchmarkCompressionWrite(b, CompressionNone) }
+func BenchmarkCompressionGzip(b *testing.B) { benchmarkCompressionWrite(b, CompressionGzip) }
+func BenchmarkCompressionZstd(b *testing.B) { benchmarkCompressionWrite(b, CompressionZstd) }
@@ -0,0 +1,142 @@
+package uploader
+
+import (
+	"container/list"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const regexIgnoredMetricPrefix = "regex:"
+
+// ignoredMetricsMatcher decides whether a metric name should be treated as
+// "ignored" (i.e. only the __name__ tag is kept). It supports exact names,
+// a wildcard "*" matching everything, shell-style glob patterns (matched
+// with filepath.Match against the metric path) and "regex:<expr>" patterns.
+// Decisions are cached in a bounded LRU since the same metric name is
+// looked up on every occurrence of the metric.
+type ignoredMetricsMatcher struct {
+	exact    map[string]bool
+	matchAll bool
+	globs    []string
+	regexes  []*regexp.Regexp
+
+	mu         sync.Mutex
+	cacheLimit int
+	cacheMap   map[string]*list.Element
+	cacheList  *list.List
+}
+
+type ignoredMetricsCacheEntry struct {
+	name    string
+	ignored bool
+}
+
+const defaultIgnoredMetricsCacheSize = 10000
+
+func newIgnoredMetricsMatcher(patterns []string) (*ignoredMetricsMatcher, error) {
+	m := &ignoredMetricsMatcher{
+		exact:      make(map[string]bool),
+		cacheLimit: defaultIgnoredMetricsCacheSize,
+		cacheMap:   make(map[string]*list.Element),
+		cacheList:  list.New(),
+	}
+
+	for _, p := range patterns {
+		switch {
+		case p == "*":
+			m.matchAll = true
+		case strings.HasPrefix(p, regexIgnoredMetricPrefix):
+			expr := strings.TrimPrefix(p, regexIgnoredMetricPrefix)
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("ignored_tagged_metrics: invalid regex %q: %w", p, err)
+			}
+			m.regexes = append(m.regexes, re)
+		case strings.ContainsAny(p, "*?["):
+			// filepath.Match validates the pattern syntax independently of the
+			// name being matched, so an empty probe name is enough to catch a
+			// malformed pattern (ErrBadPattern) at construction time.
+			if _, err := filepath.Match(p, ""); err != nil {
+				return nil, fmt.Errorf("ignored_tagged_metrics: invalid glob %q: %w", p, err)
+			}
+			m.globs = append(m.globs, p)
+		default:
+			m.exact[p] = true
+		}
+	}
+
+	return m, nil
+}
+
+// Match reports whether name should be ignored, i.e. only __name__ kept.
+func (m *ignoredMetricsMatcher) Match(name string) bool {
+	if m.matchAll {
+		return true
+	}
+	if m.exact[name] {
+		return true
+	}
+	if len(m.globs) == 0 && len(m.regexes) == 0 {
+		return false
+	}
+
+	if ignored, ok := m.cacheGet(name); ok {
+		return ignored
+	}
+
+	ignored := m.matchSlow(name)
+	m.cacheSet(name, ignored)
+	return ignored
+}
+
+func (m *ignoredMetricsMatcher) matchSlow(name string) bool {
+	for _, g := range m.globs {
+		if ok, err := filepath.Match(g, name); err == nil && ok {
+			return true
+		}
+	}
+	for _, re := range m.regexes {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *ignoredMetricsMatcher) cacheGet(name string) (bool, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.cacheMap[name]
+	if !ok {
+		return false, false
+	}
+	m.cacheList.MoveToFront(e)
+	return e.Value.(*ignoredMetricsCacheEntry).ignored, true
+}
+
+func (m *ignoredMetricsMatcher) cacheSet(name string, ignored bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.cacheMap[name]; ok {
+		e.Value.(*ignoredMetricsCacheEntry).ignored = ignored
+		m.cacheList.MoveToFront(e)
+		return
+	}
+
+	e := m.cacheList.PushFront(&ignoredMetricsCacheEntry{name: name, ignored: ignored})
+	m.cacheMap[name] = e
+
+	for m.cacheList.Len() > m.cacheLimit {
+		oldest := m.cacheList.Back()
+		if oldest == nil {
+			break
+		}
+		m.cacheList.Remove(oldest)
+		delete(m.cacheMap, oldest.Value.(*ignoredMetricsCacheEntry).name)
+	}
+}
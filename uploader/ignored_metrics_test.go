@@ -0,0 +1,31 @@
+package uploader
+
+import "testing"
+
+func TestNewIgnoredMetricsMatcherInvalidRegex(t *testing.T) {
+	if _, err := newIgnoredMetricsMatcher([]string{"regex:("}); err == nil {
+		t.Fatal("expected an error for an invalid regex pattern")
+	}
+}
+
+func TestNewIgnoredMetricsMatcherInvalidGlob(t *testing.T) {
+	if _, err := newIgnoredMetricsMatcher([]string{"app.[.cache_hits"}); err == nil {
+		t.Fatal("expected an error for a malformed glob pattern")
+	}
+}
+
+func TestIgnoredMetricsMatcherGlobAndRegex(t *testing.T) {
+	m, err := newIgnoredMetricsMatcher([]string{"app.*.cache_hits", "regex:^debug\\..*$"})
+	if err != nil {
+		t.Fatalf("newIgnoredMetricsMatcher: %v", err)
+	}
+
+	for _, name := range []string{"app.web.cache_hits", "debug.anything"} {
+		if !m.Match(name) {
+			t.Errorf("expected %q to be ignored", name)
+		}
+	}
+	if m.Match("app.web.requests") {
+		t.Errorf("expected app.web.requests not to be ignored")
+	}
+}
@@ -11,14 +11,18 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/lomik/carbon-clickhouse/helper/RowBinary"
+	"github.com/lomik/carbon-clickhouse/relabel"
 )
 
 type Tagged struct {
 	*cached
 
-	ignoredMetrics map[string]bool
-	routeTags      map[string]string // tag name to column name
-	extraColumns   []string          // names of columns which follow after Version column
+	ignoredMetrics    *ignoredMetricsMatcher
+	relabelRules      []*relabel.Rule
+	cardinalityLimits map[string]*cardinalityLimit // tag name to its cardinality tracker
+	cardinalityOrder  []string                     // cardinalityLimits keys, in config order, for stable iteration
+	routeTags         map[string]string            // tag name to column name
+	extraColumns      []string                     // names of columns which follow after Version column
 }
 
 var (
@@ -26,9 +30,13 @@ var (
 	_ UploaderWithReset = &Tagged{}
 )
 
-func NewTagged(base *Base) *Tagged {
+func NewTagged(base *Base) (*Tagged, error) {
 	u := &Tagged{}
-	u.cached = newCached(base)
+	cached, err := newCached(base)
+	if err != nil {
+		return nil, err
+	}
+	u.cached = cached
 	u.cached.parser = u.parseFile
 
 	u.routeTags = make(map[string]string)
@@ -48,12 +56,33 @@ func NewTagged(base *Base) *Tagged {
 	query.WriteString(")")
 	u.query = query.String()
 
-	u.ignoredMetrics = make(map[string]bool, len(u.config.IgnoredTaggedMetrics))
-	for _, metric := range u.config.IgnoredTaggedMetrics {
-		u.ignoredMetrics[metric] = true
+	ignoredMetrics, err := newIgnoredMetricsMatcher(u.config.IgnoredTaggedMetrics)
+	if err != nil {
+		return nil, fmt.Errorf("ignored_tagged_metrics: %w", err)
+	}
+	u.ignoredMetrics = ignoredMetrics
+
+	u.relabelRules = make([]*relabel.Rule, 0, len(u.config.RelabelConfigs))
+	for _, cfg := range u.config.RelabelConfigs {
+		rule, err := relabel.NewRule(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("relabel_configs: %w", err)
+		}
+		u.relabelRules = append(u.relabelRules, rule)
+	}
+
+	u.cardinalityLimits = make(map[string]*cardinalityLimit, len(u.config.TagCardinalityLimits))
+	u.cardinalityOrder = make([]string, 0, len(u.config.TagCardinalityLimits))
+	for _, limitCfg := range u.config.TagCardinalityLimits {
+		limit, err := newCardinalityLimit(limitCfg.Tag, limitCfg.Limit, CardinalityPolicy(limitCfg.Policy), u.stat)
+		if err != nil {
+			return nil, fmt.Errorf("tag_cardinality_limits: %w", err)
+		}
+		u.cardinalityLimits[limitCfg.Tag] = limit
+		u.cardinalityOrder = append(u.cardinalityOrder, limitCfg.Tag)
 	}
 
-	return u
+	return u, nil
 }
 
 func urlParse(rawUrl string) (*url.URL, error) {
@@ -71,6 +100,20 @@ func urlParse(rawUrl string) (*url.URL, error) {
 	return m, err
 }
 
+// rewritePathName replaces the path segment of raw (everything before its
+// first '?') with name, re-escaped the same way urlParse unescapes it,
+// leaving the query string untouched.
+func rewritePathName(raw []byte, name string) []byte {
+	p := bytes.IndexByte(raw, '?')
+	if p < 0 {
+		return raw
+	}
+	out := make([]byte, 0, len(name)+len(raw)-p)
+	out = append(out, url.PathEscape(name)...)
+	out = append(out, raw[p:]...)
+	return out
+}
+
 func (u *Tagged) parseFile(filename string, out io.Writer) (map[string]bool, error) {
 	var reader *RowBinary.Reader
 	var err error
@@ -91,8 +134,9 @@ func (u *Tagged) parseFile(filename string, out io.Writer) (map[string]bool, err
 	defer tagsBuf.Release()
 
 	var (
-		extraValues map[string]string
-		tagsList    []string
+		extraValues    map[string]string
+		tagsList       []string
+		pendingCommits []cardinalityCommit
 	)
 
 LineLoop:
@@ -136,6 +180,74 @@ LineLoop:
 			continue
 		}
 
+		// The tags map (and the extra m.Query() pass needed to build it) is
+		// only worth paying for when relabeling or cardinality limiting is
+		// actually configured; the common case below iterates m.Query()
+		// directly instead.
+		var tags map[string]string
+		needsTagsMap := len(u.relabelRules) > 0 || len(u.cardinalityLimits) > 0
+		metricName := m.Path
+
+		if needsTagsMap {
+			tags = make(map[string]string, len(m.Query())+1)
+			tags["__name__"] = m.Path
+			for name, values := range m.Query() {
+				tags[name] = values[0]
+			}
+
+			if len(u.relabelRules) > 0 {
+				var keep bool
+				tags, keep = relabel.Apply(u.relabelRules, tags)
+				if !keep {
+					continue LineLoop
+				}
+			}
+
+			if len(u.cardinalityLimits) > 0 {
+				// Decide the record's fate before touching any limiter's
+				// LRU: committing a tag's value up front would spend its
+				// budget even when the record is ultimately dropped by a
+				// sibling tag's policy (or never committed at all).
+				pendingCommits = pendingCommits[:0]
+				dropped := false
+
+				for _, tag := range u.cardinalityOrder {
+					limit := u.cardinalityLimits[tag]
+					value, ok := tags[tag]
+					if !ok {
+						continue
+					}
+
+					allowed, needsCommit := limit.allow(value)
+					if allowed {
+						if needsCommit {
+							pendingCommits = append(pendingCommits, cardinalityCommit{limit, value})
+						}
+						continue
+					}
+
+					switch limit.policy {
+					case CardinalityDropTag:
+						delete(tags, tag)
+					case CardinalityOverflowValue:
+						tags[tag] = cardinalityOverflowValue
+					default: // CardinalityDropRecord
+						dropped = true
+					}
+				}
+
+				if dropped {
+					continue LineLoop
+				}
+
+				for _, c := range pendingCommits {
+					c.limit.commit(c.value)
+				}
+			}
+
+			metricName = tags["__name__"]
+		}
+
 		newTagged[key] = true
 
 		wb.Reset()
@@ -143,26 +255,56 @@ LineLoop:
 		tagsList = tagsList[:0]
 		extraValues = make(map[string]string)
 
-		t := fmt.Sprintf("%s=%s", "__name__", m.Path)
+		// A relabel rule may have rewritten __name__ (e.g. replace/hashmod
+		// targeting it); when that happens the Path column must be rebuilt
+		// from the new name too, or it would still encode the old one while
+		// the Name/Tags columns show the new one.
+		path := name
+		if metricName != m.Path {
+			path = rewritePathName(name, metricName)
+		}
+
+		t := fmt.Sprintf("%s=%s", "__name__", metricName)
 		tagsList = append(tagsList, t)
 		tagsBuf.WriteString(t)
 
 		// don't upload any other tag but __name__
-		// if either main metric (m.Path) or each metric (*) is ignored
-		ignoreAllButName := u.ignoredMetrics[m.Path] || u.ignoredMetrics["*"]
+		// if the main metric name matches an ignored pattern
+		ignoreAllButName := u.ignoredMetrics.Match(metricName)
 		tagsWritten := 1
-		for name, values := range m.Query() {
-			if column, ok := u.routeTags[name]; ok {
-				extraValues[column] = values[0]
-				continue
-			}
-
-			t := fmt.Sprintf("%s=%s", name, values[0])
-			tagsBuf.WriteString(t)
-			tagsWritten++
 
-			if !ignoreAllButName {
-				tagsList = append(tagsList, t)
+		if needsTagsMap {
+			for name, value := range tags {
+				if name == "__name__" {
+					continue
+				}
+				if column, ok := u.routeTags[name]; ok {
+					extraValues[column] = value
+					continue
+				}
+
+				t := fmt.Sprintf("%s=%s", name, value)
+				tagsBuf.WriteString(t)
+				tagsWritten++
+
+				if !ignoreAllButName {
+					tagsList = append(tagsList, t)
+				}
+			}
+		} else {
+			for name, values := range m.Query() {
+				if column, ok := u.routeTags[name]; ok {
+					extraValues[column] = values[0]
+					continue
+				}
+
+				t := fmt.Sprintf("%s=%s", name, values[0])
+				tagsBuf.WriteString(t)
+				tagsWritten++
+
+				if !ignoreAllButName {
+					tagsList = append(tagsList, t)
+				}
 			}
 		}
 
@@ -170,7 +312,7 @@ LineLoop:
 			// base columns set
 			wb.WriteUint16(reader.Days())
 			wb.WriteString(tagsList[i])
-			wb.WriteBytes(name)
+			wb.WriteBytes(path)
 			wb.WriteUVarint(uint64(tagsWritten))
 			wb.Write(tagsBuf.Bytes())
 			wb.WriteUint32(version)
@@ -190,6 +332,16 @@ LineLoop:
 	return newTagged, nil
 }
 
+// Reset calls through to cached.Reset and clears the distinct-value
+// trackers backing the per-tag cardinality limiters, so a tag that hit its
+// cap in one cycle gets a clean window in the next.
+func (u *Tagged) Reset() {
+	u.cached.Reset()
+	for _, limit := range u.cardinalityLimits {
+		limit.reset()
+	}
+}
+
 func byteIsASCIILetter(b byte) bool {
 	const (
 		uppercaseA = 65
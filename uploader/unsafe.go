@@ -0,0 +1,9 @@
+package uploader
+
+import "unsafe"
+
+// unsafeString views b as a string without copying. The caller must not
+// mutate b for as long as the returned string is in use.
+func unsafeString(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}